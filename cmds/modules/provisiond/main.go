@@ -48,6 +48,9 @@ const (
 
 	// deprecated, kept for migration
 	fsStorageDB = "workloads"
+
+	// time given to the job in progress to finish on shutdown
+	drainTimeout = 30 * time.Second
 )
 
 // Module entry point
@@ -280,6 +283,8 @@ func action(cli *cli.Context) error {
 		provision.WithTwins(users),
 		provision.WithAdmins(admins),
 		provision.WithSubstrate(node, mgr),
+		// sign all workloads results with the node key
+		provision.WithResultSigner(kp),
 		// set priority to some reservation types on boot
 		// so we always need to make sure all volumes and networks
 		// comes first.
@@ -299,6 +304,7 @@ func action(cli *cli.Context) error {
 		// be called. this one used by the setter to set used
 		// capacity on chain.
 		provision.WithCallback(setter.Callback),
+		provision.WithDrainTimeout(drainTimeout),
 	)
 
 	if err != nil {
@@ -325,7 +331,9 @@ func action(cli *cli.Context) error {
 	}
 
 	// spawn the engine
+	engineDone := make(chan struct{})
 	go func() {
+		defer close(engineDone)
 		if err := engine.Run(ctx); err != nil && err != context.Canceled {
 			log.Fatal().Err(err).Msg("provision engine exited unexpectedely")
 		}
@@ -397,7 +405,16 @@ func action(cli *cli.Context) error {
 		return errors.Wrap(err, "message bus error")
 	}
 
-	log.Info().Msg("provision engine stopped")
+	if ctx.Err() != nil {
+		// give the engine the chance to finish the job in progress
+		select {
+		case <-engineDone:
+			log.Info().Msg("provision engine stopped")
+		case <-time.After(drainTimeout + 10*time.Second):
+			log.Error().Msg("provision engine did not stop in time, exiting anyway")
+		}
+	}
+
 	return nil
 }
 
@@ -5,6 +5,7 @@ import (
 	"crypto/ed25519"
 	"crypto/rand"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"testing"
 
@@ -70,3 +71,84 @@ func TestSignVerify(t *testing.T) {
 	getter := keyGetter{twin: 1, key: pk}
 	require.NoError(t, dl.Verify(&getter))
 }
+
+func TestResultSignVerify(t *testing.T) {
+	require := require.New(t)
+
+	pk, sk, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(err)
+
+	id, err := substrate.NewIdentityFromEd25519Key(sk)
+	require.NoError(err)
+
+	wid := NewUncheckedWorkloadID(1, 2, "wl")
+	result := Result{
+		Created: 1000,
+		State:   StateOk,
+		Data:    json.RawMessage(`{"ip": "10.0.0.1", "port": 8080}`),
+	}
+
+	require.Error(result.Verify(wid, 1, Ed25519VerifyingKey(pk)))
+	require.NoError(result.Sign(wid, 1, id))
+	require.NoError(result.Verify(wid, 1, Ed25519VerifyingKey(pk)))
+
+	// formatting and keys order of the data does not change the signature
+	result.Data = json.RawMessage(`{"port":8080,"ip":"10.0.0.1"}`)
+	require.NoError(result.Verify(wid, 1, Ed25519VerifyingKey(pk)))
+
+	// survives encoding
+	bytes, err := json.Marshal(result)
+	require.NoError(err)
+	var loaded Result
+	require.NoError(json.Unmarshal(bytes, &loaded))
+	require.NoError(loaded.Verify(wid, 1, Ed25519VerifyingKey(pk)))
+
+	// tampering
+	loaded.State = StateError
+	require.Error(loaded.Verify(wid, 1, Ed25519VerifyingKey(pk)))
+
+	other, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(err)
+	require.Error(result.Verify(wid, 1, Ed25519VerifyingKey(other)))
+
+	// signature is only valid for the same workload and version
+	require.Error(result.Verify(NewUncheckedWorkloadID(1, 3, "wl"), 1, Ed25519VerifyingKey(pk)))
+	require.Error(result.Verify(wid, 2, Ed25519VerifyingKey(pk)))
+}
+
+func TestResultChallenge(t *testing.T) {
+	require := require.New(t)
+
+	id := NewUncheckedWorkloadID(1, 2, "wl")
+	challenge := func(r Result) []byte {
+		var buf bytes.Buffer
+		require.NoError(r.Challenge(&buf, id, 1))
+		return buf.Bytes()
+	}
+
+	// moving data between fields changes the challenge
+	require.NotEqual(
+		challenge(Result{Created: 1, State: StateError, Error: "footransient"}),
+		challenge(Result{Created: 1, State: StateError, Error: "foo", Code: "transient"}),
+	)
+
+	require.Equal(
+		challenge(Result{Created: 1, State: StateOk, Data: json.RawMessage(`{"b": [1, 2], "a": {"d": 1, "c": 2}}`)}),
+		challenge(Result{Created: 1, State: StateOk, Data: json.RawMessage(`{"a":{"c":2,"d":1},"b":[1,2]}`)}),
+	)
+
+	require.NotEqual(
+		challenge(Result{Created: 1, State: StateOk, Data: json.RawMessage(`{"a":1}`)}),
+		challenge(Result{Created: 1, State: StateOk, Data: json.RawMessage(`{"a":2}`)}),
+	)
+
+	// empty data is encoded as null
+	require.Equal(
+		challenge(Result{Created: 1, State: StateOk}),
+		challenge(Result{Created: 1, State: StateOk, Data: json.RawMessage(`null`)}),
+	)
+
+	var buf bytes.Buffer
+	err := (&Result{Created: 1, State: StateOk, Data: json.RawMessage(`{invalid`)}).Challenge(&buf, id, 1)
+	require.Error(err)
+}
@@ -2,6 +2,7 @@ package gridtypes
 
 import (
 	"bytes"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -233,7 +234,7 @@ func (s ResultState) IsAny(state ...ResultState) bool {
 }
 
 func (s ResultState) IsOkay() bool {
-	return s.IsAny(StateOk, StatePaused)
+	return s.IsAny(StateOk, StatePaused, StateDegraded)
 }
 
 const (
@@ -250,14 +251,32 @@ const (
 	StateDeleted ResultState = "deleted"
 	// StatePaused constant
 	StatePaused ResultState = "paused"
+	// StateDegraded means the workload is deployed and running but it's
+	// not fully healthy (for example a vm that booted but failed a health check)
+	StateDegraded ResultState = "degraded"
 )
 
 var (
 	validStates = []ResultState{
-		StateInit, StateUnChanged, StateError, StateOk, StateDeleted, StatePaused,
+		StateInit, StateUnChanged, StateError, StateOk, StateDeleted, StatePaused, StateDegraded,
 	}
 )
 
+// ResultCode is a machine readable code that explains
+// the result state. It's optional and can be empty
+type ResultCode string
+
+const (
+	// CodeOutOfResources the node does not have enough resources
+	// to run the workload
+	CodeOutOfResources ResultCode = "out-of-resources"
+	// CodeInvalidConfig the workload configuration is not valid
+	CodeInvalidConfig ResultCode = "invalid-config"
+	// CodeTransient the operation failed because of a temporary
+	// failure and can be tried again
+	CodeTransient ResultCode = "transient"
+)
+
 // Result is the struct filled by the node
 // after a reservation object has been processed
 type Result struct {
@@ -268,9 +287,16 @@ type Result struct {
 	// if State is "error", then this field contains the error
 	// otherwise it's nil
 	Error string `json:"message"`
+	// Code is an optional machine readable code that explains
+	// the State (and the Error if set)
+	Code ResultCode `json:"code,omitempty"`
 	// Data is the information generated by the provisioning of the workload
 	// its type depend on the reservation type
 	Data json.RawMessage `json:"data"`
+	// Signature is an optional hex encoded signature of the result
+	// by the node key. The signature is computed over the Challenge
+	// of the result (see Sign and Verify)
+	Signature string `json:"signature,omitempty"`
 }
 
 func (r *Result) Valid() error {
@@ -294,6 +320,109 @@ func (r *Result) Valid() error {
 	return nil
 }
 
+// Challenge computes challenge for the result of the workload with the given
+// id and version. All fields are included except the Signature, this way
+// a signed result is only valid for the workload (version) it was produced
+// for. Each field is prefixed with its length so no two different results
+// can have the same challenge, and Data is written in its canonical form
+// (compact, with sorted object keys) so the challenge does not depend on
+// how the data was formatted.
+func (r *Result) Challenge(w io.Writer, id WorkloadID, version uint32) error {
+	data, err := canonicalJSON(r.Data)
+	if err != nil {
+		return errors.Wrap(err, "invalid result data")
+	}
+
+	fields := []string{
+		id.String(),
+		fmt.Sprint(version),
+		fmt.Sprint(r.Created),
+		string(r.State),
+		r.Error,
+		string(r.Code),
+		string(data),
+	}
+
+	for _, field := range fields {
+		if _, err := fmt.Fprintf(w, "%d:%s", len(field), field); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// canonicalJSON re-encodes data so that equal json values always have the
+// same encoding. Empty data is the same as null, which is how an empty
+// json.RawMessage is encoded.
+func canonicalJSON(data json.RawMessage) ([]byte, error) {
+	if len(data) == 0 {
+		return nullRaw, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// keep numbers as is
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	// maps are encoded with sorted keys
+	return json.Marshal(value)
+}
+
+func (r *Result) challenge(id WorkloadID, version uint32) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := r.Challenge(&buf, id, version); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Sign sets the signature of the result of the workload with given id
+// and version using the given (node) key. The challenge is signed as is
+// (it's not hashed first)
+func (r *Result) Sign(id WorkloadID, version uint32, sk Signer) error {
+	message, err := r.challenge(id, version)
+	if err != nil {
+		return err
+	}
+
+	signature, err := sk.Sign(message)
+	if err != nil {
+		return err
+	}
+
+	r.Signature = hex.EncodeToString(signature)
+	return nil
+}
+
+// Verify verifies the result signature of the workload with given id
+// and version against the given (node) key
+func (r *Result) Verify(id WorkloadID, version uint32, pk Verifier) error {
+	if len(r.Signature) == 0 {
+		return fmt.Errorf("result is not signed")
+	}
+
+	signature, err := hex.DecodeString(r.Signature)
+	if err != nil {
+		return errors.Wrap(err, "invalid signature")
+	}
+
+	message, err := r.challenge(id, version)
+	if err != nil {
+		return err
+	}
+
+	if !pk.Verify(message, signature) {
+		return fmt.Errorf("invalid result signature")
+	}
+
+	return nil
+}
+
 // Unmarshal a shortcut for json.Unmarshal
 func (r *Result) Unmarshal(v interface{}) error {
 	return json.Unmarshal(r.Data, v)
@@ -319,6 +448,8 @@ var (
 
 // Bytes returns a slice of bytes container all the information
 // used to sign the Result object
+//
+// Deprecated: results are signed over their Challenge, see Sign
 func (r *Result) Bytes() ([]byte, error) {
 	buf := &bytes.Buffer{}
 	if _, err := buf.WriteString(string(r.State)); err != nil {
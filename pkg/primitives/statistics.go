@@ -163,7 +163,10 @@ func (s *Statistics) Provision(ctx context.Context, wl *gridtypes.WorkloadWithID
 	} // TODO: other types ?
 
 	if err := s.hasEnoughCapacity(&needed); err != nil {
-		return result, errors.Wrap(err, "failed to satisfy required capacity")
+		return result, provision.WithCode(
+			errors.Wrap(err, "failed to satisfy required capacity"),
+			gridtypes.CodeOutOfResources,
+		)
 	}
 
 	ctx = context.WithValue(ctx, currentCapacityKey{}, current)
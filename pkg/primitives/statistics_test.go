@@ -0,0 +1,45 @@
+package primitives
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zos/pkg/gridtypes"
+	"github.com/threefoldtech/zos/pkg/gridtypes/zos"
+	"github.com/threefoldtech/zos/pkg/provision"
+)
+
+type testProvisioner struct {
+	provision.Provisioner
+	called bool
+}
+
+func (p *testProvisioner) Provision(ctx context.Context, wl *gridtypes.WorkloadWithID) (gridtypes.Result, error) {
+	p.called = true
+	return gridtypes.Result{Created: gridtypes.Now(), State: gridtypes.StateOk}, nil
+}
+
+func TestStatisticsOutOfResources(t *testing.T) {
+	require := require.New(t)
+
+	var inner testProvisioner
+	total := gridtypes.Capacity{CRU: 1, MRU: gridtypes.Gigabyte}
+	statistics := NewStatistics(total, gridtypes.Capacity{}, Counters{}, &inner)
+
+	data, err := json.Marshal(zos.ZMachine{
+		ComputeCapacity: zos.MachineCapacity{CPU: 1, Memory: 10 * gridtypes.Gigabyte},
+	})
+	require.NoError(err)
+
+	wl := gridtypes.WorkloadWithID{
+		Workload: &gridtypes.Workload{Type: zos.ZMachineType, Name: "vm", Data: data},
+		ID:       gridtypes.NewUncheckedWorkloadID(1, 1, "vm"),
+	}
+
+	_, err = statistics.Provision(context.Background(), &wl)
+	require.Error(err)
+	require.Equal(gridtypes.CodeOutOfResources, provision.ErrorCode(err))
+	require.False(inner.called)
+}
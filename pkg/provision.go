@@ -12,6 +12,12 @@ import (
 // Provision interface
 type Provision interface {
 	DecommissionCached(id string, reason string) error
+	// Cordon stops the node from accepting new deployments
+	Cordon()
+	// Uncordon allows the node to accept new deployments again
+	Uncordon()
+	// Cordoned checks if the node is cordoned
+	Cordoned() bool
 }
 
 type Statistics interface {
@@ -6,9 +6,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime/debug"
 	"sort"
+	"sync/atomic"
 	"time"
 
+	"github.com/cenkalti/backoff/v3"
 	"github.com/joncrlsn/dque"
 	"github.com/pkg/errors"
 	"github.com/threefoldtech/substrate-client"
@@ -55,6 +58,54 @@ func WithRerunAll(t bool) EngineOption {
 	return &withRerunAll{t}
 }
 
+// WithRetry makes the engine retry workloads that fail to provision with
+// a Retryable error. A workload is tried at most attempts+1 times, waiting
+// delay before the first retry and multiplying the wait by factor after
+// each failed attempt. The wait is never longer than 10 seconds, and a factor
+// below 1 means the same delay is used for all retries. Retries stop if the
+// engine context is cancelled. By default there are no retries.
+// Note that the engine processes one job at a time, so waiting for a retry
+// holds up all other jobs in the queue. That's why retries are not enabled
+// in provisiond, and none of the zos managers return Retryable errors yet.
+func WithRetry(attempts uint64, delay time.Duration, factor float64) EngineOption {
+	return &withRetry{attempts, delay, factor}
+}
+
+// WithDrainTimeout sets how long the engine waits for the job in progress
+// to finish after the engine context is cancelled, before the job context
+// itself is cancelled. Defaults to 30 seconds. A cancelled job is kept in
+// the queue, so it's processed again when the engine is started again.
+func WithDrainTimeout(d time.Duration) EngineOption {
+	return &withDrainTimeout{d}
+}
+
+// Metrics is used by the engine to report its operations. An implementation
+// can for example feed the values to prometheus counters, histograms and gauges
+type Metrics interface {
+	// Processed is called once an operation on a workload of type typ has
+	// finished with the given result state after spending duration in the
+	// provisioner
+	Processed(typ gridtypes.WorkloadType, state gridtypes.ResultState, duration time.Duration)
+	// QueueSize is called with the number of jobs in the engine queue
+	// (including the one being processed) every time a job is picked
+	// or done
+	QueueSize(n int)
+}
+
+// WithMetrics sets the metrics sink of the engine
+func WithMetrics(m Metrics) EngineOption {
+	return &withMetrics{m}
+}
+
+// WithResultSigner makes the engine sign all workload results with the
+// given key (usually the node key) so consumers can verify that a result
+// was produced by this node. See gridtypes.Result.Verify
+// Only results set by the engine are signed. The initial `init` results and
+// the contract validation errors are set by the storage and are not signed.
+func WithResultSigner(sk gridtypes.Signer) EngineOption {
+	return &withResultSigner{sk}
+}
+
 type Callback func(twin uint32, contract uint64, delete bool)
 
 // WithCallback sets a callback that is called when a deployment is being Created, Updated, Or Deleted
@@ -118,6 +169,12 @@ type NativeEngine struct {
 	nodeID   uint32
 	sub      substrate.Manager
 	callback Callback
+	retry    withRetry
+	metrics  Metrics
+	drain    time.Duration
+	signer   gridtypes.Signer
+	// cordoned is set (to 1) when engine is cordoned
+	cordoned uint32
 }
 
 var _ Engine = (*NativeEngine)(nil)
@@ -192,6 +249,50 @@ func (w *withCallback) apply(e *NativeEngine) {
 	e.callback = w.cb
 }
 
+type withRetry struct {
+	attempts uint64
+	delay    time.Duration
+	factor   float64
+}
+
+func (w *withRetry) apply(e *NativeEngine) {
+	e.retry = *w
+	if e.retry.factor < 1 {
+		e.retry.factor = 1
+	}
+}
+
+type withDrainTimeout struct {
+	d time.Duration
+}
+
+func (w *withDrainTimeout) apply(e *NativeEngine) {
+	e.drain = w.d
+}
+
+type withResultSigner struct {
+	sk gridtypes.Signer
+}
+
+func (w *withResultSigner) apply(e *NativeEngine) {
+	e.signer = w.sk
+}
+
+type withMetrics struct {
+	m Metrics
+}
+
+func (w *withMetrics) apply(e *NativeEngine) {
+	e.metrics = w.m
+}
+
+type nullMetrics struct{}
+
+func (n *nullMetrics) Processed(typ gridtypes.WorkloadType, state gridtypes.ResultState, duration time.Duration) {
+}
+
+func (n *nullMetrics) QueueSize(int) {}
+
 type nullKeyGetter struct{}
 
 func (n *nullKeyGetter) GetKey(id uint32) ([]byte, error) {
@@ -280,6 +381,8 @@ func New(storage Storage, provisioner Provisioner, root string, opts ...EngineOp
 		provisioner: provisioner,
 		twins:       &nullKeyGetter{},
 		admins:      &nullKeyGetter{},
+		metrics:     &nullMetrics{},
+		drain:       30 * time.Second,
 		order:       gridtypes.Types(),
 		typeIndex:   make(map[gridtypes.WorkloadType]int),
 	}
@@ -319,8 +422,32 @@ func (e *NativeEngine) Admins() Twins {
 	return e.admins
 }
 
+// Cordon stops the engine from accepting new deployments or updates to
+// existing ones. Already deployed workloads keep running, and can still
+// be deleted, paused or resumed. Jobs that were accepted before the engine
+// was cordoned are still processed.
+func (e *NativeEngine) Cordon() {
+	atomic.StoreUint32(&e.cordoned, 1)
+	log.Info().Msg("provision engine cordoned")
+}
+
+// Uncordon allows the engine to accept new deployments again
+func (e *NativeEngine) Uncordon() {
+	atomic.StoreUint32(&e.cordoned, 0)
+	log.Info().Msg("provision engine uncordoned")
+}
+
+// Cordoned checks if the engine is cordoned
+func (e *NativeEngine) Cordoned() bool {
+	return atomic.LoadUint32(&e.cordoned) == 1
+}
+
 // Provision workload
 func (e *NativeEngine) Provision(ctx context.Context, deployment gridtypes.Deployment) error {
+	if e.Cordoned() {
+		return ErrCordoned
+	}
+
 	if deployment.Version != 0 {
 		return errors.Wrap(ErrInvalidVersion, "expected version to be 0 on deployment creation")
 	}
@@ -401,6 +528,10 @@ func (e *NativeEngine) Deprovision(ctx context.Context, twin uint32, id uint64,
 
 // Update workloads
 func (e *NativeEngine) Update(ctx context.Context, update gridtypes.Deployment) error {
+	if e.Cordoned() {
+		return ErrCordoned
+	}
+
 	deployment, err := e.storage.Get(update.TwinID, update.ContractID)
 	if err != nil {
 		return err
@@ -464,15 +595,21 @@ func (e *NativeEngine) Run(root context.Context) error {
 	}
 
 	for {
-		obj, err := e.queue.PeekBlock()
-		if err != nil {
+		job, err := e.next(root)
+		if root.Err() != nil {
+			return root.Err()
+		} else if err != nil {
 			log.Error().Err(err).Msg("failed to check job queue")
 			<-time.After(2 * time.Second)
 			continue
 		}
 
-		job := obj.(*engineJob)
-		ctx := withDeployment(root, job.Target.TwinID, job.Target.ContractID)
+		e.metrics.QueueSize(e.queue.Size())
+
+		// the job context is not cancelled directly with root so
+		// a job in progress can finish when the engine is stopped
+		jobCtx, cancel := e.drainable(root)
+		ctx := withDeployment(jobCtx, job.Target.TwinID, job.Target.ContractID)
 		l := log.With().
 			Uint32("twin", job.Target.TwinID).
 			Uint64("contract", job.Target.ContractID).
@@ -492,6 +629,7 @@ func (e *NativeEngine) Run(root context.Context) error {
 					l.Error().Err(err).Msg("failed to set deployment global error")
 				}
 				_, _ = e.queue.Dequeue()
+				cancel()
 
 				continue
 			}
@@ -531,15 +669,127 @@ func (e *NativeEngine) Run(root context.Context) error {
 			e.updateDeployment(ctx, update)
 		}
 
+		if jobCtx.Err() != nil {
+			// the job did not finish within the drain timeout. It's
+			// kept in the queue so it's processed again on next start
+			l.Warn().Msg("job cancelled, it will be retried on next start")
+			cancel()
+			continue
+		}
+
 		_, err = e.queue.Dequeue()
 		if err != nil {
 			l.Error().Err(err).Msg("failed to dequeue job")
 		}
 
+		e.metrics.QueueSize(e.queue.Size())
+
 		e.safeCallback(&job.Target, job.Op == opDeprovision)
+		cancel()
 	}
 }
 
+// next blocks until a job is available in the queue or ctx is cancelled.
+// The job is not removed from the queue.
+func (e *NativeEngine) next(ctx context.Context) (*engineJob, error) {
+	type peek struct {
+		obj interface{}
+		err error
+	}
+
+	ch := make(chan peek, 1)
+	go func() {
+		// PeekBlock can't be interrupted, on cancellation this routine
+		// stays blocked until the queue is closed or a new job is pushed.
+		obj, err := e.queue.PeekBlock()
+		ch <- peek{obj, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case p := <-ch:
+		if p.err != nil {
+			return nil, p.err
+		}
+		return p.obj.(*engineJob), nil
+	}
+}
+
+// detached is a context that carries the values of its parent
+// but not its deadline or cancellation
+type detached struct {
+	context.Context
+}
+
+func (d detached) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (d detached) Done() <-chan struct{} {
+	return nil
+}
+
+func (d detached) Err() error {
+	return nil
+}
+
+// drainable returns a context with all values of root, that is only cancelled
+// once the drain timeout has passed after root is cancelled.
+func (e *NativeEngine) drainable(root context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(detached{root})
+	go func() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-root.Done():
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(e.drain):
+			log.Warn().Msg("job in progress did not finish in time, cancelling")
+			cancel()
+		}
+	}()
+
+	return ctx, cancel
+}
+
+type panicError struct {
+	v interface{}
+}
+
+func (p *panicError) Error() string {
+	return fmt.Sprintf("panic while processing workload: %v", p.v)
+}
+
+func isPanic(err error) bool {
+	var p *panicError
+	return errors.As(err, &p)
+}
+
+// safe runs fn and turns a panic into an error, this way
+// a faulty provisioner can't bring the whole engine down
+func safe(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error().Str("stack", string(debug.Stack())).Msgf("panic while processing workload: %v", r)
+			err = &panicError{r}
+		}
+	}()
+
+	return fn()
+}
+
+// unchanged returns the current result of the workload marked as
+// unchanged because of err
+func unchanged(wl *gridtypes.WorkloadWithID, err error) gridtypes.Result {
+	result := wl.Result
+	setState(&result, UnChanged(err))
+	return result
+}
+
 func (e *NativeEngine) safeCallback(d *gridtypes.Deployment, delete bool) {
 	if e.callback == nil {
 		return
@@ -666,15 +916,20 @@ func (e *NativeEngine) uninstallWorkload(ctx context.Context, wl *gridtypes.Work
 		State: gridtypes.StateDeleted,
 		Error: reason,
 	}
-	if err := e.provisioner.Deprovision(ctx, wl); err != nil {
+	start := time.Now()
+	err = safe(func() error {
+		return e.provisioner.Deprovision(ctx, wl)
+	})
+	if err != nil {
 		log.Error().Err(err).Stringer("id", wl.ID).Msg("failed to uninstall workload")
 		result.State = gridtypes.StateError
 		result.Error = err.Error()
 	}
+	e.metrics.Processed(wl.Type, result.State, time.Since(start))
 
 	result.Created = gridtypes.Timestamp(time.Now().Unix())
 
-	if err := e.storage.Transaction(twin, deployment, wl.Workload.WithResults(result)); err != nil {
+	if err := e.transaction(ctx, twin, deployment, wl.Workload, result); err != nil {
 		return err
 	}
 
@@ -720,23 +975,92 @@ func (e *NativeEngine) installWorkload(ctx context.Context, wl *gridtypes.Worklo
 		Logger()
 
 	log.Debug().Msg("provisioning")
-	result, err := e.provisioner.Provision(ctx, wl)
+	result, spent, err := e.provision(ctx, wl)
 	if errors.Is(err, ErrNoActionNeeded) {
 		// workload already exist, so no need to create a new transaction
+		log.Debug().Err(err).Msg("no action needed")
 		return nil
 	} else if err != nil {
+		result.Created = gridtypes.Now()
 		result.State = gridtypes.StateError
 		result.Error = err.Error()
+		result.Code = ErrorCode(err)
 	}
+	e.metrics.Processed(wl.Type, result.State, spent)
 
 	if result.State == gridtypes.StateError {
 		log.Error().Str("error", result.Error).Msg("failed to deploy workload")
 	}
 
-	return e.storage.Transaction(
-		twin,
-		deployment,
-		wl.Workload.WithResults(result))
+	return e.transaction(ctx, twin, deployment, wl.Workload, result)
+}
+
+// transaction signs the result (if a signer is set) and then
+// appends it to the workload transactions. A failed result is not
+// stored if the job was cancelled, since the job is then run again
+// on next start.
+func (e *NativeEngine) transaction(ctx context.Context, twin uint32, deployment uint64, wl *gridtypes.Workload, result gridtypes.Result) error {
+	if ctx.Err() != nil && result.State.IsAny(gridtypes.StateError, gridtypes.StateUnChanged) {
+		return errors.Wrap(ctx.Err(), "job cancelled, result is not stored")
+	}
+
+	if e.signer != nil {
+		id := gridtypes.NewUncheckedWorkloadID(twin, deployment, wl.Name)
+		if err := result.Sign(id, wl.Version, e.signer); err != nil {
+			return errors.Wrap(err, "failed to sign workload result")
+		}
+	}
+
+	return e.storage.Transaction(twin, deployment, wl.WithResults(result))
+}
+
+// provision calls the provisioner and retries on Retryable errors
+// according to the engine retry settings. It also returns the time
+// spent in the provisioner (not including the waits between retries)
+func (e *NativeEngine) provision(ctx context.Context, wl *gridtypes.WorkloadWithID) (result gridtypes.Result, spent time.Duration, err error) {
+	provision := func() error {
+		start := time.Now()
+		defer func() {
+			spent += time.Since(start)
+		}()
+
+		return safe(func() (err error) {
+			result, err = e.provisioner.Provision(ctx, wl)
+			return err
+		})
+	}
+
+	if e.retry.attempts == 0 {
+		err = provision()
+		return result, spent, err
+	}
+
+	exp := backoff.NewExponentialBackOff()
+	exp.InitialInterval = e.retry.delay
+	exp.Multiplier = e.retry.factor
+	exp.MaxInterval = 10 * time.Second
+	// waits are exactly delay * factor^n
+	exp.RandomizationFactor = 0
+	// number of attempts is what limits the retries
+	exp.MaxElapsedTime = 0
+
+	bo := backoff.WithContext(backoff.WithMaxRetries(exp, e.retry.attempts), ctx)
+	err = backoff.RetryNotify(func() error {
+		err = provision()
+		if err == nil || IsRetryable(err) {
+			return err
+		}
+
+		return backoff.Permanent(err)
+	}, bo, func(err error, d time.Duration) {
+		log.Warn().
+			Err(err).
+			Stringer("id", wl.ID).
+			Str("sleep", d.String()).
+			Msg("failed to provision workload, retrying")
+	})
+
+	return result, spent, err
 }
 
 func (e *NativeEngine) updateWorkload(ctx context.Context, wl *gridtypes.WorkloadWithID) error {
@@ -752,8 +1076,12 @@ func (e *NativeEngine) updateWorkload(ctx context.Context, wl *gridtypes.Workloa
 
 	var result gridtypes.Result
 	var err error
+	start := time.Now()
 	if e.provisioner.CanUpdate(ctx, wl.Type) {
-		result, err = e.provisioner.Update(ctx, wl)
+		err = safe(func() error {
+			result, err = e.provisioner.Update(ctx, wl)
+			return err
+		})
 	} else {
 		// deprecated. We should never update resources by decomission and then provsion
 		// the check in Update method should prevent this
@@ -762,12 +1090,19 @@ func (e *NativeEngine) updateWorkload(ctx context.Context, wl *gridtypes.Workloa
 	}
 
 	if errors.Is(err, ErrNoActionNeeded) {
+		log.Debug().Err(err).Msg("no action needed")
 		return nil
+	} else if isPanic(err) {
+		// the workload is left as is, but the failure is
+		// recorded in the workload changes
+		result = unchanged(wl, err)
 	} else if err != nil {
+		e.metrics.Processed(wl.Type, gridtypes.StateError, time.Since(start))
 		return err
 	}
 
-	return e.storage.Transaction(twin, deployment, wl.Workload.WithResults(result))
+	e.metrics.Processed(wl.Type, result.State, time.Since(start))
+	return e.transaction(ctx, twin, deployment, wl.Workload, result)
 }
 
 func (e *NativeEngine) lockWorkload(ctx context.Context, wl *gridtypes.WorkloadWithID, lock bool) error {
@@ -799,22 +1134,32 @@ func (e *NativeEngine) lockWorkload(ctx context.Context, wl *gridtypes.WorkloadW
 	if lock {
 		action = e.provisioner.Pause
 	}
-	result, err := action(ctx, wl)
+	start := time.Now()
+	var result gridtypes.Result
+	err = safe(func() error {
+		result, err = action(ctx, wl)
+		return err
+	})
 	if errors.Is(err, ErrNoActionNeeded) {
 		// workload already exist, so no need to create a new transaction
+		log.Debug().Err(err).Msg("no action needed")
 		return nil
+	} else if isPanic(err) {
+		// the workload is left as is, but the failure is
+		// recorded in the workload changes
+		result = unchanged(wl, err)
 	} else if err != nil {
+		e.metrics.Processed(wl.Type, gridtypes.StateError, time.Since(start))
 		return err
 	}
 
+	e.metrics.Processed(wl.Type, result.State, time.Since(start))
+
 	if result.State == gridtypes.StateError {
 		log.Error().Str("error", result.Error).Msg("failed to set locking on workload")
 	}
 
-	return e.storage.Transaction(
-		twin,
-		deployment,
-		wl.Workload.WithResults(result))
+	return e.transaction(ctx, twin, deployment, wl.Workload, result)
 }
 
 func (e *NativeEngine) uninstallDeployment(ctx context.Context, getter gridtypes.WorkloadGetter, reason string) {
@@ -1,5 +1,21 @@
 package provision
 
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/substrate-client"
+	"github.com/threefoldtech/zos/pkg/gridtypes"
+)
+
 // func TestEngine(t *testing.T) {
 // 	td, err := ioutil.TempDir("", "")
 // 	require.NoError(t, err)
@@ -68,3 +84,532 @@ package provision
 // 	assert.EqualValues(t, 1, workloads.ZDBNamespace)
 // 	assert.EqualValues(t, 0, workloads.K8sVM)
 // }
+
+type testProvisioner struct {
+	provision   func(ctx context.Context, wl *gridtypes.WorkloadWithID) (gridtypes.Result, error)
+	deprovision func(ctx context.Context, wl *gridtypes.WorkloadWithID) error
+	update      func(ctx context.Context, wl *gridtypes.WorkloadWithID) (gridtypes.Result, error)
+	pause       func(ctx context.Context, wl *gridtypes.WorkloadWithID) (gridtypes.Result, error)
+}
+
+func (p *testProvisioner) Initialize(ctx context.Context) error {
+	return nil
+}
+
+func (p *testProvisioner) Provision(ctx context.Context, wl *gridtypes.WorkloadWithID) (gridtypes.Result, error) {
+	return p.provision(ctx, wl)
+}
+
+func (p *testProvisioner) Deprovision(ctx context.Context, wl *gridtypes.WorkloadWithID) error {
+	if p.deprovision == nil {
+		return nil
+	}
+	return p.deprovision(ctx, wl)
+}
+
+func (p *testProvisioner) Pause(ctx context.Context, wl *gridtypes.WorkloadWithID) (gridtypes.Result, error) {
+	if p.pause == nil {
+		return wl.Result, nil
+	}
+	return p.pause(ctx, wl)
+}
+
+func (p *testProvisioner) Resume(ctx context.Context, wl *gridtypes.WorkloadWithID) (gridtypes.Result, error) {
+	return wl.Result, nil
+}
+
+func (p *testProvisioner) Update(ctx context.Context, wl *gridtypes.WorkloadWithID) (gridtypes.Result, error) {
+	if p.update == nil {
+		return wl.Result, nil
+	}
+	return p.update(ctx, wl)
+}
+
+func (p *testProvisioner) CanUpdate(ctx context.Context, typ gridtypes.WorkloadType) bool {
+	return p.update != nil
+}
+
+func TestEngineRetry(t *testing.T) {
+	require := require.New(t)
+
+	calls := 0
+	provisioner := &testProvisioner{
+		provision: func(ctx context.Context, wl *gridtypes.WorkloadWithID) (gridtypes.Result, error) {
+			calls++
+			if calls < 3 {
+				return gridtypes.Result{}, Retryable(fmt.Errorf("storage is busy"))
+			}
+			return gridtypes.Result{Created: gridtypes.Now(), State: gridtypes.StateOk}, nil
+		},
+	}
+
+	engine := &NativeEngine{provisioner: provisioner}
+	WithRetry(5, time.Millisecond, 2).apply(engine)
+
+	wl := gridtypes.WorkloadWithID{Workload: &gridtypes.Workload{Type: testWorkloadType}}
+	result, _, err := engine.provision(context.Background(), &wl)
+	require.NoError(err)
+	require.Equal(gridtypes.StateOk, result.State)
+	require.Equal(3, calls)
+
+	// permanent errors are never retried
+	calls = 0
+	provisioner.provision = func(ctx context.Context, wl *gridtypes.WorkloadWithID) (gridtypes.Result, error) {
+		calls++
+		return gridtypes.Result{}, fmt.Errorf("invalid config")
+	}
+
+	_, _, err = engine.provision(context.Background(), &wl)
+	require.EqualError(err, "invalid config")
+	require.Equal(1, calls)
+
+	// retries stop once the attempts are exhausted
+	calls = 0
+	provisioner.provision = func(ctx context.Context, wl *gridtypes.WorkloadWithID) (gridtypes.Result, error) {
+		calls++
+		return gridtypes.Result{}, Retryable(fmt.Errorf("storage is busy"))
+	}
+
+	_, _, err = engine.provision(context.Background(), &wl)
+	require.True(IsRetryable(err))
+	require.Equal(6, calls)
+
+	// and once the context is cancelled
+	calls = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err = engine.provision(ctx, &wl)
+	require.True(IsRetryable(err))
+	require.Equal(1, calls)
+
+	// the waits between retries are not counted as time spent provisioning
+	calls = 0
+	provisioner.provision = func(ctx context.Context, wl *gridtypes.WorkloadWithID) (gridtypes.Result, error) {
+		calls++
+		if calls < 3 {
+			return gridtypes.Result{}, Retryable(fmt.Errorf("storage is busy"))
+		}
+		return gridtypes.Result{Created: gridtypes.Now(), State: gridtypes.StateOk}, nil
+	}
+	WithRetry(5, 50*time.Millisecond, 1).apply(engine)
+
+	start := time.Now()
+	_, spent, err := engine.provision(context.Background(), &wl)
+	require.NoError(err)
+	require.GreaterOrEqual(time.Since(start), 100*time.Millisecond)
+	require.Less(spent, 50*time.Millisecond)
+}
+
+// testStorage implements only the storage methods needed by the
+// engine to (un)install a workload
+type testStorage struct {
+	Storage
+	workloads map[gridtypes.Name]gridtypes.Workload
+}
+
+func (s *testStorage) Current(twin uint32, deployment uint64, name gridtypes.Name) (gridtypes.Workload, error) {
+	wl, ok := s.workloads[name]
+	if !ok {
+		return wl, ErrWorkloadNotExist
+	}
+	return wl, nil
+}
+
+func (s *testStorage) Create(deployment gridtypes.Deployment) error {
+	for _, wl := range deployment.Workloads {
+		s.workloads[wl.Name] = wl
+	}
+	return nil
+}
+
+func (s *testStorage) Get(twin uint32, deployment uint64) (gridtypes.Deployment, error) {
+	dl := gridtypes.Deployment{TwinID: twin, ContractID: deployment}
+	for _, wl := range s.workloads {
+		dl.Workloads = append(dl.Workloads, wl)
+	}
+	return dl, nil
+}
+
+func (s *testStorage) Add(twin uint32, deployment uint64, workload gridtypes.Workload) error {
+	s.workloads[workload.Name] = workload
+	return nil
+}
+
+func (s *testStorage) Transaction(twin uint32, deployment uint64, workload gridtypes.Workload) error {
+	// same validation done by the bolt storage
+	if err := workload.Result.Valid(); err != nil {
+		return errors.Wrap(err, "failed to validate workload result")
+	}
+	s.workloads[workload.Name] = workload
+	return nil
+}
+
+func (s *testStorage) Remove(twin uint32, deployment uint64, name gridtypes.Name) error {
+	delete(s.workloads, name)
+	return nil
+}
+
+// newTestEngine creates an engine (with no job queue) that stores results
+// in a test storage, so the workload operations can be called directly
+func newTestEngine(provisioner Provisioner, opts ...EngineOption) (*NativeEngine, *testStorage) {
+	storage := &testStorage{workloads: make(map[gridtypes.Name]gridtypes.Workload)}
+	engine := &NativeEngine{
+		storage:     storage,
+		provisioner: provisioner,
+		metrics:     &nullMetrics{},
+	}
+
+	for _, opt := range opts {
+		opt.apply(engine)
+	}
+
+	return engine, storage
+}
+
+// testWorkload creates a workload of the test type with given name
+func testWorkload(name gridtypes.Name) gridtypes.WorkloadWithID {
+	return gridtypes.WorkloadWithID{
+		Workload: &gridtypes.Workload{Type: testWorkloadType, Name: name},
+		ID:       gridtypes.NewUncheckedWorkloadID(1, 1, name),
+	}
+}
+
+type testMetrics struct {
+	processed map[gridtypes.ResultState]int
+}
+
+func (m *testMetrics) Processed(typ gridtypes.WorkloadType, state gridtypes.ResultState, duration time.Duration) {
+	m.processed[state]++
+}
+
+func (m *testMetrics) QueueSize(n int) {}
+
+func TestEngineRetryExhausted(t *testing.T) {
+	require := require.New(t)
+
+	var mgr testManagerFull
+	engine, storage := newTestEngine(
+		NewMapProvisioner(map[gridtypes.WorkloadType]Manager{testWorkloadType: &mgr}),
+		WithRetry(2, time.Millisecond, 0),
+	)
+	require.EqualValues(1, engine.retry.factor)
+
+	wl := testWorkload("wl")
+
+	mgr.On("Provision", mock.Anything, &wl).Return(123, Retryable(fmt.Errorf("storage is busy")))
+	require.NoError(engine.installWorkload(context.Background(), &wl))
+	mgr.AssertNumberOfCalls(t, "Provision", 3)
+
+	// once retries are exhausted the workload is stored in error state
+	result := storage.workloads["wl"].Result
+	require.Equal(gridtypes.StateError, result.State)
+	require.Equal("storage is busy", result.Error)
+	require.Equal(gridtypes.CodeTransient, result.Code)
+	require.Equal(json.RawMessage("123"), result.Data)
+}
+
+func TestEngineUpdate(t *testing.T) {
+	require := require.New(t)
+
+	var mgr testManagerUpdater
+	engine, storage := newTestEngine(
+		NewMapProvisioner(map[gridtypes.WorkloadType]Manager{testWorkloadType: &mgr}),
+	)
+
+	wl := testWorkload("disk")
+	wl.Result = gridtypes.Result{
+		Created: gridtypes.Now(),
+		State:   gridtypes.StateOk,
+		Data:    json.RawMessage(`{"id":"disk"}`),
+	}
+
+	// a successful resize (like the zmount manager does) is recorded
+	mgr.On("Update", mock.Anything, &wl).Return(map[string]string{"id": "disk"}, nil)
+	require.NoError(engine.updateWorkload(context.Background(), &wl))
+	result := storage.workloads["disk"].Result
+	require.Equal(gridtypes.StateOk, result.State)
+	require.Empty(result.Error)
+	require.Equal(json.RawMessage(`{"id":"disk"}`), result.Data)
+
+	// a failed resize keeps the disk as is
+	mgr.ExpectedCalls = nil
+	mgr.On("Update", mock.Anything, &wl).Return(nil, UnChanged(fmt.Errorf("failed to resize disk")))
+	require.NoError(engine.updateWorkload(context.Background(), &wl))
+	result = storage.workloads["disk"].Result
+	require.Equal(gridtypes.StateUnChanged, result.State)
+	require.Equal("failed to resize disk", result.Error)
+	require.Equal(json.RawMessage(`{"id":"disk"}`), result.Data)
+}
+
+func TestEngineMetrics(t *testing.T) {
+	require := require.New(t)
+
+	metrics := &testMetrics{processed: make(map[gridtypes.ResultState]int)}
+	provisioner := &testProvisioner{
+		provision: func(ctx context.Context, wl *gridtypes.WorkloadWithID) (gridtypes.Result, error) {
+			if wl.Name == "bad" {
+				return gridtypes.Result{}, fmt.Errorf("failed to deploy")
+			}
+			return gridtypes.Result{Created: gridtypes.Now(), State: gridtypes.StateOk}, nil
+		},
+	}
+
+	engine, _ := newTestEngine(provisioner, WithMetrics(metrics))
+
+	good := testWorkload("good")
+	bad := testWorkload("bad")
+
+	ctx := context.Background()
+	require.NoError(engine.installWorkload(ctx, &good))
+	require.NoError(engine.installWorkload(ctx, &bad))
+	require.Equal(1, metrics.processed[gridtypes.StateOk])
+	require.Equal(1, metrics.processed[gridtypes.StateError])
+
+	require.NoError(engine.uninstallWorkload(ctx, &good, "test"))
+	require.Equal(1, metrics.processed[gridtypes.StateDeleted])
+}
+
+// drainTest is the outcome of runDrainTest, stopped is the time the engine
+// was stopped and cancelled is the time the job context was cancelled (zero
+// if it was not)
+type drainTest struct {
+	storage *testStorage
+	root    string
+
+	stopped   time.Time
+	cancelled time.Time
+}
+
+// runDrainTest runs an engine with a single deprovision job that takes
+// duration to finish (unless cancelled), and stops the engine once the
+// job has started.
+func runDrainTest(t *testing.T, drain, duration time.Duration) drainTest {
+	require := require.New(t)
+
+	test := drainTest{
+		storage: &testStorage{workloads: map[gridtypes.Name]gridtypes.Workload{
+			"wl": {Type: testWorkloadType, Name: "wl"},
+		}},
+		root: t.TempDir(),
+	}
+
+	started := make(chan struct{})
+	provisioner := &testProvisioner{
+		deprovision: func(ctx context.Context, wl *gridtypes.WorkloadWithID) error {
+			close(started)
+			select {
+			case <-ctx.Done():
+				test.cancelled = time.Now()
+				return ctx.Err()
+			case <-time.After(duration):
+				return nil
+			}
+		},
+	}
+
+	engine, err := New(test.storage, provisioner, test.root, WithDrainTimeout(drain))
+	require.NoError(err)
+	engine.order = []gridtypes.WorkloadType{testWorkloadType}
+
+	err = engine.queue.Enqueue(&engineJob{
+		Op: opDeprovision,
+		Target: gridtypes.Deployment{
+			TwinID:     1,
+			ContractID: 1,
+			Workloads:  []gridtypes.Workload{{Type: testWorkloadType, Name: "wl"}},
+		},
+	})
+	require.NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- engine.Run(ctx)
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(3 * time.Second):
+		require.Fail("job was not started")
+	}
+	test.stopped = time.Now()
+	cancel()
+
+	select {
+	case err := <-done:
+		require.ErrorIs(err, context.Canceled)
+	case <-time.After(3 * time.Second):
+		require.Fail("engine did not stop")
+	}
+
+	return test
+}
+
+func TestEngineDrain(t *testing.T) {
+	require := require.New(t)
+
+	test := runDrainTest(t, 5*time.Second, 200*time.Millisecond)
+
+	// the slow job has finished (and was not cancelled) before Run returned
+	require.True(test.cancelled.IsZero())
+	require.Empty(test.storage.workloads)
+}
+
+func TestEngineDrainTimeout(t *testing.T) {
+	require := require.New(t)
+
+	drain := 100 * time.Millisecond
+	test := runDrainTest(t, drain, 5*time.Second)
+
+	// the job context was cancelled, but only after the drain timeout
+	require.False(test.cancelled.IsZero())
+	require.GreaterOrEqual(test.cancelled.Sub(test.stopped), drain)
+
+	// the cancellation error is not stored
+	wl, ok := test.storage.workloads["wl"]
+	require.True(ok)
+	require.True(wl.Result.IsNil())
+
+	// and the job is still queued so it runs again on next start
+	engine, err := New(test.storage, &testProvisioner{}, test.root)
+	require.NoError(err)
+	require.Equal(1, engine.queue.Size())
+}
+
+func TestEnginePanic(t *testing.T) {
+	require := require.New(t)
+
+	provisioner := &testProvisioner{
+		provision: func(ctx context.Context, wl *gridtypes.WorkloadWithID) (gridtypes.Result, error) {
+			if wl.Name == "bad" {
+				var m map[string]int
+				m["crash"] = 1
+			}
+			return gridtypes.Result{Created: gridtypes.Now(), State: gridtypes.StateOk}, nil
+		},
+	}
+
+	engine, storage := newTestEngine(provisioner)
+
+	bad := testWorkload("bad")
+	good := testWorkload("good")
+
+	ctx := context.Background()
+	require.NoError(engine.installWorkload(ctx, &bad))
+	require.Equal(gridtypes.StateError, storage.workloads["bad"].Result.State)
+	require.Contains(storage.workloads["bad"].Result.Error, "panic while processing workload")
+	require.NotZero(storage.workloads["bad"].Result.Created)
+
+	// the engine still processes the next workload
+	require.NoError(engine.installWorkload(ctx, &good))
+	require.Equal(gridtypes.StateOk, storage.workloads["good"].Result.State)
+}
+
+func TestEnginePanicUnChanged(t *testing.T) {
+	require := require.New(t)
+
+	crash := func(ctx context.Context, wl *gridtypes.WorkloadWithID) (gridtypes.Result, error) {
+		var m map[string]int
+		m["crash"] = 1
+		return wl.Result, nil
+	}
+	engine, storage := newTestEngine(&testProvisioner{update: crash, pause: crash})
+
+	wl := testWorkload("wl")
+	wl.Result = gridtypes.Result{
+		Created: gridtypes.Now(),
+		State:   gridtypes.StateOk,
+		Data:    json.RawMessage(`{"ip":"10.0.0.1"}`),
+	}
+	storage.workloads["wl"] = *wl.Workload
+
+	ctx := context.Background()
+	for _, op := range []func() error{
+		func() error { return engine.updateWorkload(ctx, &wl) },
+		func() error { return engine.lockWorkload(ctx, &wl, true) },
+	} {
+		// the failure is stored, but the workload data is kept
+		require.NoError(op())
+		result := storage.workloads["wl"].Result
+		require.Equal(gridtypes.StateUnChanged, result.State)
+		require.Contains(result.Error, "panic while processing workload")
+		require.Equal(json.RawMessage(`{"ip":"10.0.0.1"}`), result.Data)
+	}
+}
+
+func TestEngineErrorCode(t *testing.T) {
+	require := require.New(t)
+
+	engine, storage := newTestEngine(&testProvisioner{
+		provision: func(ctx context.Context, wl *gridtypes.WorkloadWithID) (gridtypes.Result, error) {
+			return gridtypes.Result{}, WithCode(fmt.Errorf("not enough memory"), gridtypes.CodeOutOfResources)
+		},
+	})
+
+	wl := testWorkload("wl")
+
+	// the provisioner error comes with an empty result, the engine still
+	// needs to build a valid error result that is accepted by the storage
+	require.NoError(engine.installWorkload(context.Background(), &wl))
+	result := storage.workloads["wl"].Result
+	require.NoError(result.Valid())
+	require.Equal(gridtypes.StateError, result.State)
+	require.Equal("not enough memory", result.Error)
+	require.Equal(gridtypes.CodeOutOfResources, result.Code)
+}
+
+func TestEngineCordon(t *testing.T) {
+	require := require.New(t)
+
+	storage := &testStorage{workloads: make(map[gridtypes.Name]gridtypes.Workload)}
+	engine, err := New(storage, &testProvisioner{}, t.TempDir())
+	require.NoError(err)
+
+	deployment := gridtypes.Deployment{
+		TwinID:     1,
+		ContractID: 1,
+		Workloads:  []gridtypes.Workload{{Type: testWorkloadType, Name: "wl"}},
+	}
+
+	ctx := context.Background()
+	require.NoError(engine.Provision(ctx, deployment))
+
+	engine.Cordon()
+	require.True(engine.Cordoned())
+
+	deployment.ContractID = 2
+	require.ErrorIs(engine.Provision(ctx, deployment), ErrCordoned)
+	deployment.Version = 1
+	require.ErrorIs(engine.Update(ctx, deployment), ErrCordoned)
+
+	// deleting is still allowed
+	require.NoError(engine.Deprovision(ctx, 1, 1, "test"))
+	require.Equal(2, engine.queue.Size())
+
+	engine.Uncordon()
+	require.False(engine.Cordoned())
+	deployment.Version = 0
+	require.NoError(engine.Provision(ctx, deployment))
+}
+
+func TestEngineResultSigner(t *testing.T) {
+	require := require.New(t)
+
+	pk, sk, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(err)
+	id, err := substrate.NewIdentityFromEd25519Key(sk)
+	require.NoError(err)
+
+	engine, storage := newTestEngine(&testProvisioner{
+		provision: func(ctx context.Context, wl *gridtypes.WorkloadWithID) (gridtypes.Result, error) {
+			return buildResult(map[string]string{"ip": "10.0.0.1"}, nil)
+		},
+	}, WithResultSigner(id))
+
+	wl := testWorkload("wl")
+
+	require.NoError(engine.installWorkload(context.Background(), &wl))
+	result := storage.workloads["wl"].Result
+	require.NotEmpty(result.Signature)
+	require.NoError(result.Verify(wl.ID, wl.Version, gridtypes.Ed25519VerifyingKey(pk)))
+}
@@ -33,7 +33,9 @@ type Engine interface {
 // fails to deploy and this is returned as Error state in the Result object (but nil error)
 // Methods can return special error type ErrDidNotChange which instructs the engine that the
 // workload provision was not carried on because it's already deployed, basically a no action
-// needed indicator. In that case, the engine can ignore the returned result
+// needed indicator. In that case, the engine can ignore the returned result.
+// Provision can also return a Retryable error which the engine can retry
+// (if configured to do so) before it marks the workload as failed
 type Provisioner interface {
 	// Initialize is called before the provision engine is started
 	Initialize(ctx context.Context) error
@@ -68,13 +70,17 @@ var (
 	// ErrNoActionNeeded can be returned by any provision method to indicate that
 	// no action has been taken in case a workload is already deployed and the
 	// engine then can skip updating the result of the workload.
-	// When returned, the data returned by the provision is ignored
+	// When returned, the data returned by the provision is ignored. It can be
+	// wrapped to give the reason no action was needed, which is then logged
 	ErrNoActionNeeded = fmt.Errorf("no action needed")
 	// ErrDeploymentUpgradeValidationError error, is returned if the deployment
 	// failed to compute upgrade steps
 	ErrDeploymentUpgradeValidationError = fmt.Errorf("upgrade validation error")
 	// ErrInvalidVersion invalid version error
 	ErrInvalidVersion = fmt.Errorf("invalid version")
+	// ErrCordoned returned if the engine is cordoned and
+	// can't accept new deployments or updates
+	ErrCordoned = fmt.Errorf("node is cordoned, not accepting new deployments")
 )
 
 // Field interface
@@ -50,6 +50,8 @@ func (d *Deployments) createOrUpdate(ctx context.Context, payload []byte, update
 
 	if err == context.DeadlineExceeded {
 		return nil, mw.Unavailable(ctx.Err())
+	} else if errors.Is(err, provision.ErrCordoned) {
+		return nil, mw.Unavailable(err)
 	} else if errors.Is(err, provision.ErrDeploymentExists) {
 		return nil, mw.Conflict(err)
 	} else if errors.Is(err, provision.ErrDeploymentNotExists) {
@@ -56,15 +56,73 @@ func Ok() Response {
 // UnChanged is a special response status that states that an operation has failed
 // but this did not affect the workload status. Usually during an update when the
 // update could not carried out, but the workload is still running correctly with
-// previous config
+// previous config. When returned from an Update the data of the previous result
+// is kept in the new result, any data returned by the manager is ignored.
 func UnChanged(cause error) Response {
 	return &response{s: gridtypes.StateUnChanged, e: cause}
 }
 
+// Degraded response states that the workload is deployed and running but
+// is not fully healthy, for example a vm that booted but failed a health check.
+// Use UnChanged instead if an operation failed but the workload is still
+// running correctly.
+func Degraded(cause error) Response {
+	return &response{s: gridtypes.StateDegraded, e: cause}
+}
+
 func Paused() Response {
 	return &response{s: gridtypes.StatePaused, e: fmt.Errorf("paused")}
 }
 
+type coded struct {
+	c gridtypes.ResultCode
+	e error
+}
+
+func (c *coded) Error() string {
+	return c.e.Error()
+}
+
+func (c *coded) Unwrap() error {
+	return c.e
+}
+
+// WithCode attaches a machine readable code to err. err can be any of
+// the responses (Ok, UnChanged, Degraded, Paused) or a normal error.
+// The code is then set on the workload result, while the state is still
+// derived from err as usual. A Provisioner can also return a coded error
+// in which case the workload ends up in error state with that code.
+func WithCode(err error, code gridtypes.ResultCode) error {
+	return &coded{c: code, e: err}
+}
+
+type retryable struct {
+	e error
+}
+
+func (r *retryable) Error() string {
+	return r.e.Error()
+}
+
+func (r *retryable) Unwrap() error {
+	return r.e
+}
+
+// Retryable marks an error as transient (network hiccup, busy storage, etc...)
+// A manager can return a Retryable error from Provision to tell the engine
+// that the operation might succeed if it's tried again. Retries are only
+// carried out if the engine is configured with WithRetry, otherwise the
+// workload ends up in error state as with any other error.
+func Retryable(cause error) error {
+	return &retryable{e: cause}
+}
+
+// IsRetryable checks if err (or any error it wraps) was marked as Retryable
+func IsRetryable(err error) bool {
+	var r *retryable
+	return errors.As(err, &r)
+}
+
 // Manager defines basic type manager functionality. This interface
 // declares the provision and the deprovision method which is required
 // by any Type manager.
@@ -131,7 +189,15 @@ func (p *mapProvisioner) Provision(ctx context.Context, wl *gridtypes.WorkloadWi
 		return result, err
 	}
 
-	return buildResult(data, err)
+	result, berr := buildResult(data, err)
+	if berr == nil && IsRetryable(err) {
+		// retryable errors are returned as provisioner errors
+		// so the engine can decide to try again. The result is
+		// still complete in case the engine gives up.
+		return result, err
+	}
+
+	return result, berr
 }
 
 // Decommission implementation for provision.Provisioner
@@ -146,8 +212,8 @@ func (p *mapProvisioner) Deprovision(ctx context.Context, wl *gridtypes.Workload
 
 // Pause a workload
 func (p *mapProvisioner) Pause(ctx context.Context, wl *gridtypes.WorkloadWithID) (gridtypes.Result, error) {
-	if wl.Result.State != gridtypes.StateOk {
-		return wl.Result, fmt.Errorf("can only pause workloads in ok state")
+	if !wl.Result.State.IsAny(gridtypes.StateOk, gridtypes.StateDegraded) {
+		return wl.Result, fmt.Errorf("can only pause workloads in ok or degraded state")
 	}
 
 	manager, ok := p.managers[wl.Type]
@@ -168,6 +234,10 @@ func (p *mapProvisioner) Pause(ctx context.Context, wl *gridtypes.WorkloadWithID
 	// does not change across pause/resume changes
 	result := wl.Result
 	setState(&result, err)
+	if err := ValidateResult(result); err != nil {
+		return wl.Result, errors.Wrap(err, "invalid result")
+	}
+
 	return result, nil
 }
 
@@ -194,6 +264,10 @@ func (p *mapProvisioner) Resume(ctx context.Context, wl *gridtypes.WorkloadWithI
 	// does not change across pause/resume changes
 	result := wl.Result
 	setState(&result, err)
+	if err := ValidateResult(result); err != nil {
+		return wl.Result, errors.Wrap(err, "invalid result")
+	}
+
 	return result, nil
 }
 
@@ -214,7 +288,14 @@ func (p *mapProvisioner) Update(ctx context.Context, wl *gridtypes.WorkloadWithI
 		return result, err
 	}
 
-	return buildResult(data, err)
+	result, err = buildResult(data, err)
+	if err == nil && result.State == gridtypes.StateUnChanged {
+		// the workload is still running with the previous config
+		// so it's data is still valid.
+		result.Data = wl.Result.Data
+	}
+
+	return result, err
 }
 
 func (p *mapProvisioner) CanUpdate(ctx context.Context, typ gridtypes.WorkloadType) bool {
@@ -231,6 +312,7 @@ func setState(result *gridtypes.Result, err error) {
 	result.Created = gridtypes.Timestamp(time.Now().Unix())
 	state := gridtypes.StateOk
 	str := ""
+	var code gridtypes.ResultCode
 
 	if err != nil {
 		str = err.Error()
@@ -240,15 +322,63 @@ func setState(result *gridtypes.Result, err error) {
 		if errors.As(err, &resp) {
 			state = resp.state()
 		}
+
+		code = ErrorCode(err)
 	}
 
 	result.State = state
 	result.Error = str
+	result.Code = code
+	// the signature (if any) is not valid anymore
+	result.Signature = ""
+}
+
+// ErrorCode returns the code attached to err with WithCode if any,
+// Retryable errors with no explicit code are transient.
+func ErrorCode(err error) gridtypes.ResultCode {
+	var c *coded
+	if errors.As(err, &c) {
+		return c.c
+	}
+
+	if IsRetryable(err) {
+		return gridtypes.CodeTransient
+	}
+
+	return ""
+}
+
+// ValidateResult makes sure a result produced by a provisioner is well formed.
+// On top of the gridtypes validation, a result in error, unchanged or degraded
+// state must explain why in its Error field, while an ok result must not have
+// an error.
+func ValidateResult(r gridtypes.Result) error {
+	if err := r.Valid(); err != nil {
+		return err
+	}
+
+	switch r.State {
+	case gridtypes.StateInit:
+		return fmt.Errorf("init state can't be set by a provisioner")
+	case gridtypes.StateOk:
+		if len(r.Error) != 0 {
+			return fmt.Errorf("result in ok state can't have an error")
+		}
+	case gridtypes.StateError, gridtypes.StateUnChanged, gridtypes.StateDegraded:
+		if len(r.Error) == 0 {
+			return fmt.Errorf("result in '%s' state must have an error", r.State)
+		}
+	}
+
+	return nil
 }
 
 func buildResult(data interface{}, err error) (gridtypes.Result, error) {
 	var result gridtypes.Result
 	setState(&result, err)
+	if err := ValidateResult(result); err != nil {
+		return result, errors.Wrap(err, "invalid result")
+	}
 
 	br, err := json.Marshal(data)
 	if err != nil {
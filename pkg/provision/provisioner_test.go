@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/mock"
@@ -53,6 +54,20 @@ func TestBuildResult(t *testing.T) {
 				Error: "wrapped: failed to update",
 			},
 		},
+		{
+			in: Degraded(fmt.Errorf("health check failed")),
+			out: gridtypes.Result{
+				State: gridtypes.StateDegraded,
+				Error: "health check failed",
+			},
+		},
+		{
+			in: errors.Wrap(Degraded(fmt.Errorf("health check failed")), "wrapped"),
+			out: gridtypes.Result{
+				State: gridtypes.StateDegraded,
+				Error: "wrapped: health check failed",
+			},
+		},
 		{
 			in: Paused(),
 			out: gridtypes.Result{
@@ -67,6 +82,22 @@ func TestBuildResult(t *testing.T) {
 				Error: "wrapped for some reason: paused",
 			},
 		},
+		{
+			in: WithCode(fmt.Errorf("not enough memory"), gridtypes.CodeOutOfResources),
+			out: gridtypes.Result{
+				State: gridtypes.StateError,
+				Error: "not enough memory",
+				Code:  gridtypes.CodeOutOfResources,
+			},
+		},
+		{
+			in: errors.Wrap(WithCode(UnChanged(fmt.Errorf("invalid size")), gridtypes.CodeInvalidConfig), "wrapped"),
+			out: gridtypes.Result{
+				State: gridtypes.StateUnChanged,
+				Error: "wrapped: invalid size",
+				Code:  gridtypes.CodeInvalidConfig,
+			},
+		},
 	}
 
 	for _, c := range cases {
@@ -76,10 +107,70 @@ func TestBuildResult(t *testing.T) {
 
 			require.Equal(t, c.out.State, result.State)
 			require.Equal(t, c.out.Error, result.Error)
+			require.Equal(t, c.out.Code, result.Code)
 		})
 	}
 }
 
+func TestBuildResultWithData(t *testing.T) {
+	require := require.New(t)
+
+	type config struct {
+		Size int `json:"size"`
+	}
+
+	// a non ok state can still carry data, the state is
+	// derived from the response while data is kept as is
+	result, err := buildResult(config{Size: 10}, errors.Wrap(Paused(), "contract locked"))
+	require.NoError(err)
+	require.Equal(gridtypes.StatePaused, result.State)
+	require.Equal("contract locked: paused", result.Error)
+	require.Equal(json.RawMessage(`{"size":10}`), result.Data)
+
+	result, err = buildResult(config{Size: 20}, fmt.Errorf("failed to resize"))
+	require.NoError(err)
+	require.Equal(gridtypes.StateError, result.State)
+	require.Equal(json.RawMessage(`{"size":20}`), result.Data)
+}
+
+func TestValidateResult(t *testing.T) {
+	now := gridtypes.Timestamp(time.Now().Unix())
+	type Case struct {
+		in    gridtypes.Result
+		valid bool
+	}
+	cases := []Case{
+		{in: gridtypes.Result{Created: now, State: gridtypes.StateOk}, valid: true},
+		{in: gridtypes.Result{Created: now, State: gridtypes.StateOk, Error: "failed"}, valid: false},
+		{in: gridtypes.Result{Created: now, State: gridtypes.StateError, Error: "failed"}, valid: true},
+		{in: gridtypes.Result{Created: now, State: gridtypes.StateError}, valid: false},
+		{in: gridtypes.Result{Created: now, State: gridtypes.StateUnChanged, Error: "failed"}, valid: true},
+		{in: gridtypes.Result{Created: now, State: gridtypes.StateUnChanged}, valid: false},
+		{in: gridtypes.Result{Created: now, State: gridtypes.StateDegraded, Error: "unhealthy"}, valid: true},
+		{in: gridtypes.Result{Created: now, State: gridtypes.StateDegraded}, valid: false},
+		{in: gridtypes.Result{Created: now, State: gridtypes.StatePaused, Error: "paused"}, valid: true},
+		{in: gridtypes.Result{Created: now, State: gridtypes.StateDeleted}, valid: true},
+		{in: gridtypes.Result{Created: now, State: gridtypes.StateInit}, valid: false},
+		{in: gridtypes.Result{Created: now, State: "unknown"}, valid: false},
+		{in: gridtypes.Result{State: gridtypes.StateOk}, valid: false},
+	}
+
+	for _, c := range cases {
+		t.Run(string(c.in.State), func(t *testing.T) {
+			err := ValidateResult(c.in)
+			if c.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+
+	// a response with no cause is caught when building the result
+	_, err := buildResult(nil, UnChanged(nil))
+	require.Error(t, err)
+}
+
 var (
 	testWorkloadType gridtypes.WorkloadType = "test"
 )
@@ -127,11 +218,23 @@ func TestProvision(t *testing.T) {
 	require.Equal(gridtypes.StateError, result.State)
 	require.Equal("failed to run", result.Error)
 
+	mgr.ExpectedCalls = nil
+	mgr.On("Provision", mock.Anything, &wl).Return(123, Retryable(fmt.Errorf("storage is busy")))
+	result, err = provisioner.Provision(ctx, &wl)
+
+	// retryable errors are returned, but the result is still built
+	require.True(IsRetryable(err))
+	require.NoError(result.Valid())
+	require.Equal(gridtypes.StateError, result.State)
+	require.Equal("storage is busy", result.Error)
+	require.Equal(gridtypes.CodeTransient, result.Code)
+	require.Equal(json.RawMessage("123"), result.Data)
+
 	mgr.ExpectedCalls = nil
 	mgr.On("Pause", mock.Anything, &wl).Return(nil, nil)
 	result, err = provisioner.Pause(ctx, &wl)
 
-	require.Errorf(err, "can only pause workloads in ok state")
+	require.Errorf(err, "can only pause workloads in ok or degraded state")
 
 	mgr.ExpectedCalls = nil
 	wl = gridtypes.WorkloadWithID{
@@ -150,3 +253,91 @@ func TestProvision(t *testing.T) {
 	require.NoError(err)
 	require.Equal(gridtypes.StatePaused, result.State)
 }
+
+type testManagerPauser struct {
+	testManagerFull
+}
+
+func (t *testManagerPauser) Pause(ctx context.Context, wl *gridtypes.WorkloadWithID) error {
+	args := t.Called(ctx, wl)
+	return args.Error(0)
+}
+
+func (t *testManagerPauser) Resume(ctx context.Context, wl *gridtypes.WorkloadWithID) error {
+	args := t.Called(ctx, wl)
+	return args.Error(0)
+}
+
+func TestPauseResumeInvalidResult(t *testing.T) {
+	require := require.New(t)
+	var mgr testManagerPauser
+	provisioner := NewMapProvisioner(map[gridtypes.WorkloadType]Manager{
+		testWorkloadType: &mgr,
+	})
+
+	ctx := context.Background()
+	wl := gridtypes.WorkloadWithID{
+		Workload: &gridtypes.Workload{
+			Type:   testWorkloadType,
+			Result: gridtypes.Result{State: gridtypes.StateOk},
+		},
+	}
+
+	// an unchanged response with no cause is rejected
+	mgr.On("Pause", mock.Anything, &wl).Return(UnChanged(nil))
+	_, err := provisioner.Pause(ctx, &wl)
+	require.Error(err)
+
+	mgr.ExpectedCalls = nil
+	mgr.On("Pause", mock.Anything, &wl).Return(UnChanged(fmt.Errorf("failed to lock")))
+	result, err := provisioner.Pause(ctx, &wl)
+	require.NoError(err)
+	require.Equal(gridtypes.StateUnChanged, result.State)
+
+	wl.Result.State = gridtypes.StatePaused
+	mgr.On("Resume", mock.Anything, &wl).Return(UnChanged(nil))
+	_, err = provisioner.Resume(ctx, &wl)
+	require.Error(err)
+}
+
+type testManagerUpdater struct {
+	testManagerFull
+}
+
+func (t *testManagerUpdater) Update(ctx context.Context, wl *gridtypes.WorkloadWithID) (interface{}, error) {
+	args := t.Called(ctx, wl)
+	return args.Get(0), args.Error(1)
+}
+
+func TestUpdateUnChanged(t *testing.T) {
+	require := require.New(t)
+	var mgr testManagerUpdater
+	provisioner := NewMapProvisioner(map[gridtypes.WorkloadType]Manager{
+		testWorkloadType: &mgr,
+	})
+
+	ctx := context.Background()
+	wl := gridtypes.WorkloadWithID{
+		Workload: &gridtypes.Workload{
+			Type: testWorkloadType,
+			Result: gridtypes.Result{
+				State: gridtypes.StateOk,
+				Data:  json.RawMessage(`{"size":10}`),
+			},
+		},
+	}
+
+	mgr.On("Update", mock.Anything, &wl).Return(nil, UnChanged(fmt.Errorf("not safe to shrink")))
+	result, err := provisioner.Update(ctx, &wl)
+	require.NoError(err)
+	require.Equal(gridtypes.StateUnChanged, result.State)
+	require.Equal("not safe to shrink", result.Error)
+	require.Equal(json.RawMessage(`{"size":10}`), result.Data)
+
+	mgr.ExpectedCalls = nil
+	mgr.On("Update", mock.Anything, &wl).Return(20, nil)
+	result, err = provisioner.Update(ctx, &wl)
+	require.NoError(err)
+	require.Equal(gridtypes.StateOk, result.State)
+	require.Equal(json.RawMessage(`20`), result.Data)
+}